@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"fmt"
+	"os"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
+)
+
+// Selector narrows the pods a Rule applies to. A Rule with no Namespaces and
+// no LabelSelector matches every pod.
+type Selector struct {
+	// Namespaces restricts the rule to the listed namespaces. Empty means
+	// every namespace is eligible.
+	Namespaces []string `json:"namespaces,omitempty"`
+	// LabelSelector restricts the rule to pods whose labels match. A nil
+	// selector matches every pod.
+	LabelSelector *metav1.LabelSelector `json:"labelSelector,omitempty"`
+}
+
+// Mutations describes the changes a Rule applies to a matched pod.
+type Mutations struct {
+	// Tolerations are merged into the pod's existing tolerations; an
+	// identical toleration already present on the pod is left untouched.
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+	// NodeSelector entries are set on the pod, overwriting any existing
+	// value for the same key.
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+	// Labels are set on the pod, overwriting any existing value for the
+	// same key.
+	Labels map[string]string `json:"labels,omitempty"`
+	// Annotations are set on the pod, overwriting any existing value for
+	// the same key.
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// Env variables are appended to every container in the pod; a
+	// container that already defines an env var with the same name is
+	// left untouched.
+	Env []corev1.EnvVar `json:"env,omitempty"`
+}
+
+// Rule pairs a Selector with the Mutations to apply to the pods it matches.
+type Rule struct {
+	// Name identifies the rule in logs and audit records.
+	Name string `json:"name"`
+	// Selector chooses which pods this rule applies to.
+	Selector Selector `json:"selector,omitempty"`
+	// Mutations are applied, in the order listed on the Config, to every
+	// pod the Selector matches.
+	Mutations Mutations `json:"mutations"`
+	// FailurePolicy governs what happens when this rule's Selector cannot
+	// be evaluated, e.g. because of a malformed label selector. It
+	// defaults to the Config's top-level FailurePolicy when empty, so a
+	// single bad rule can be set to fail open without loosening the
+	// cluster-wide default.
+	FailurePolicy string `json:"failurePolicy,omitempty"`
+}
+
+// effectiveFailurePolicy returns r's FailurePolicy, falling back to the
+// cluster-wide default when r does not set one of its own.
+func (r Rule) effectiveFailurePolicy() string {
+	if r.FailurePolicy != "" {
+		return r.FailurePolicy
+	}
+	return failurePolicy
+}
+
+// Config is the webhook's top-level, file-based configuration.
+type Config struct {
+	// FailurePolicy governs how the webhook responds when it cannot
+	// decode a request, unmarshal a pod, or evaluate a rule: "Fail" denies
+	// the request with a descriptive message, while "Ignore" allows it
+	// through unmutated, or, for a rule that cannot be evaluated, skips just
+	// that rule and still applies the others. It defaults to "Fail" when unset, so
+	// existing deployments keep today's closed behavior unless they opt
+	// in.
+	FailurePolicy string `json:"failurePolicy,omitempty"`
+	// Rules are evaluated in order against every admitted pod; all
+	// matching rules are applied.
+	Rules []Rule `json:"rules"`
+}
+
+// Recognized FailurePolicy values, matching the naming Kubernetes itself
+// uses for webhook configurations.
+const (
+	FailurePolicyFail   = "Fail"
+	FailurePolicyIgnore = "Ignore"
+)
+
+// rules holds the mutation rules evaluated by mutatePod. It is populated by
+// LoadConfig at startup.
+var rules []Rule
+
+// failurePolicy is the cluster-wide default applied when decoding fails or a
+// rule has no FailurePolicy of its own. It is populated by LoadConfig at
+// startup and defaults to FailurePolicyFail.
+var failurePolicy = FailurePolicyFail
+
+// LoadConfig reads and parses the YAML mutation rule configuration at path,
+// replacing the rules and failure policy evaluated by mutatePod.
+func LoadConfig(path string) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read config file %q: %w", path, err)
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(raw, &cfg); err != nil {
+		return fmt.Errorf("could not parse config file %q: %w", path, err)
+	}
+
+	rules = cfg.Rules
+	failurePolicy = FailurePolicyFail
+	if cfg.FailurePolicy != "" {
+		failurePolicy = cfg.FailurePolicy
+	}
+	return nil
+}
+
+// matches reports whether pod, admitted into namespace, is selected by s.
+func (s Selector) matches(namespace string, pod *corev1.Pod) (bool, error) {
+	if len(s.Namespaces) > 0 {
+		found := false
+		for _, ns := range s.Namespaces {
+			if ns == namespace {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false, nil
+		}
+	}
+
+	if s.LabelSelector == nil {
+		return true, nil
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(s.LabelSelector)
+	if err != nil {
+		return false, fmt.Errorf("could not parse label selector: %w", err)
+	}
+	return selector.Matches(labels.Set(pod.Labels)), nil
+}
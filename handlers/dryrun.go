@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"errors"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// DryRun runs podJSON through the same mutation pipeline serve uses for a
+// live admission request, without an HTTP round trip. It backs the
+// -test.pod CLI flag, letting operators reproduce and golden-file test a
+// webhook decision against a manifest on disk.
+func DryRun(podJSON []byte, namespace string) ([]byte, error) {
+	req := &admissionv1.AdmissionRequest{
+		UID:       types.UID("dry-run"),
+		Namespace: namespace,
+		Object: runtime.RawExtension{
+			Raw: podJSON,
+		},
+	}
+
+	resp := mutatePod(req)
+	if !resp.Allowed {
+		msg := "pod was not allowed"
+		if resp.Result != nil {
+			msg = resp.Result.Message
+		}
+		return nil, errors.New(msg)
+	}
+	return resp.Patch, nil
+}
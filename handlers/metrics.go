@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Metrics exposed on /metrics. They let operators alert on error spikes and
+// on unexpected shifts in how often rules fire.
+var (
+	admissionRequestsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "alloydb_webhook_admission_requests_total",
+		Help: "Total number of admission requests received by the webhook.",
+	})
+
+	admissionDecisionsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "alloydb_webhook_admission_decisions_total",
+		Help: "Admission decisions, partitioned by verdict (allowed, denied, errored).",
+	}, []string{"verdict"})
+
+	ruleMutationsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "alloydb_webhook_rule_mutations_total",
+		Help: "Number of times a mutation rule matched a pod and was applied, partitioned by rule name.",
+	}, []string{"rule"})
+
+	admissionRequestDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "alloydb_webhook_admission_request_duration_seconds",
+		Help:    "Latency of admission requests handled by the webhook.",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	decodeErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "alloydb_webhook_decode_errors_total",
+		Help: "Number of JSON decoding errors encountered, partitioned by stage (request, pod).",
+	}, []string{"stage"})
+)
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+
+	jsonpatch "github.com/mattbaird/jsonpatch"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// constructPatch diffs original against mutated and returns the RFC 6902
+// JSON Patch needed to turn one into the other, or a nil patch if the
+// mutation rules made no changes. Diffing the marshaled objects, rather
+// than hand-walking known fields, means a rule touching any part of the pod
+// (tolerations, nodeSelector, labels, annotations, container env) produces
+// a correct, minimal patch without the patch builder needing to know about
+// it.
+func constructPatch(original, mutated *corev1.Pod) ([]byte, error) {
+	originalJSON, err := json.Marshal(original)
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal original pod: %w", err)
+	}
+
+	mutatedJSON, err := json.Marshal(mutated)
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal mutated pod: %w", err)
+	}
+
+	ops, err := jsonpatch.CreatePatch(originalJSON, mutatedJSON)
+	if err != nil {
+		return nil, fmt.Errorf("could not diff pod objects: %w", err)
+	}
+	if len(ops) == 0 {
+		return nil, nil
+	}
+
+	return json.Marshal(ops)
+}
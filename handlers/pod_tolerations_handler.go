@@ -0,0 +1,396 @@
+// Package handlers implements the HTTP handlers backing the AlloyDB pod
+// mutating admission webhook.
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	admissionV1APIVersion = "admission.k8s.io/v1"
+	admissionReviewKind   = "AdmissionReview"
+)
+
+// AdmitFunc evaluates an admission request and returns the corresponding
+// response. It is intentionally version-agnostic: it operates on the
+// admission.k8s.io/v1 types regardless of which AdmissionReview version the
+// caller sent, so the same function can back both v1 and v1beta1 requests.
+type AdmitFunc func(*admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse
+
+// Routes registers the webhook's HTTP handlers on the default ServeMux.
+func Routes() {
+	http.HandleFunc("/mutate", func(w http.ResponseWriter, r *http.Request) {
+		serve(w, r, mutatePod)
+	})
+	http.Handle("/metrics", promhttp.Handler())
+}
+
+// meta is used to sniff the apiVersion of an incoming AdmissionReview before
+// committing to decoding it as a particular version.
+type meta struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+}
+
+// serve decodes an AdmissionReview request, dispatches it to admit and
+// writes back an AdmissionReview response in the same apiVersion it
+// received. It supports both admission.k8s.io/v1 and the deprecated
+// v1beta1, since clusters older than 1.22 only send the latter.
+func serve(w http.ResponseWriter, r *http.Request, admit AdmitFunc) {
+	if strings.Contains(r.UserAgent(), "Kubelet") {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+		http.Error(w, "invalid content-type, expect `application/json`", http.StatusBadRequest)
+		return
+	}
+
+	admissionRequestsTotal.Inc()
+	start := time.Now()
+	defer func() { admissionRequestDuration.Observe(time.Since(start).Seconds()) }()
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil || len(body) == 0 {
+		failRequest(w, "could not read request body", nil)
+		return
+	}
+
+	var m meta
+	if err := json.Unmarshal(body, &m); err != nil {
+		failRequest(w, fmt.Sprintf("could not decode request body: %v", err), nil)
+		return
+	}
+
+	var (
+		req        *admissionv1.AdmissionRequest
+		respWriter func(*admissionv1.AdmissionResponse) ([]byte, error)
+	)
+
+	if m.APIVersion == admissionV1APIVersion {
+		respWriter = func(resp *admissionv1.AdmissionResponse) ([]byte, error) {
+			return json.Marshal(admissionv1.AdmissionReview{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: admissionV1APIVersion,
+					Kind:       admissionReviewKind,
+				},
+				Response: resp,
+			})
+		}
+
+		ar := admissionv1.AdmissionReview{}
+		if err := json.Unmarshal(body, &ar); err != nil {
+			failRequest(w, fmt.Sprintf("could not decode AdmissionReview: %v", err), respWriter)
+			return
+		}
+		if ar.Request == nil {
+			failRequest(w, "admission review has no request", respWriter)
+			return
+		}
+		req = ar.Request
+	} else {
+		respWriter = func(resp *admissionv1.AdmissionResponse) ([]byte, error) {
+			return json.Marshal(v1beta1.AdmissionReview{
+				Response: toV1beta1Response(resp),
+			})
+		}
+
+		ar := v1beta1.AdmissionReview{}
+		if err := json.Unmarshal(body, &ar); err != nil {
+			failRequest(w, fmt.Sprintf("could not decode AdmissionReview: %v", err), respWriter)
+			return
+		}
+		if ar.Request == nil {
+			failRequest(w, "admission review has no request", respWriter)
+			return
+		}
+		req = toV1Request(ar.Request)
+	}
+
+	resp := admit(req)
+
+	respBytes, err := respWriter(resp)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("could not marshal response: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(respBytes); err != nil {
+		log.Printf("could not write response: %v", err)
+	}
+}
+
+// failRequest reports a request-level decoding failure: one that happens
+// before a pod object is even in hand, so there is no AdmissionRequest to
+// carry a UID on the response. It honors the cluster-wide FailurePolicy:
+// "Fail" rejects the request with StatusBadRequest and reason, while
+// "Ignore" admits it unmutated so a malformed or unexpected request can't
+// block every pod in the cluster from scheduling. respWriter marshals the
+// fail-open response in whichever apiVersion the request was detected as;
+// when the failure happens before that detection (the body isn't even
+// valid JSON), respWriter is nil and the reply falls back to v1beta1.
+func failRequest(w http.ResponseWriter, reason string, respWriter func(*admissionv1.AdmissionResponse) ([]byte, error)) {
+	decodeErrorsTotal.WithLabelValues("request").Inc()
+
+	if failurePolicy == FailurePolicyIgnore {
+		if respWriter == nil {
+			respWriter = func(resp *admissionv1.AdmissionResponse) ([]byte, error) {
+				return json.Marshal(v1beta1.AdmissionReview{Response: toV1beta1Response(resp)})
+			}
+		}
+		respBytes, err := respWriter(&admissionv1.AdmissionResponse{Allowed: true})
+		if err != nil {
+			http.Error(w, fmt.Sprintf("could not marshal fail-open response: %v", err), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(respBytes)
+		return
+	}
+
+	http.Error(w, reason, http.StatusBadRequest)
+}
+
+// toV1Request converts a v1beta1 AdmissionRequest into its v1 counterpart.
+// The two types are field-for-field identical; only the package differs.
+func toV1Request(req *v1beta1.AdmissionRequest) *admissionv1.AdmissionRequest {
+	return &admissionv1.AdmissionRequest{
+		UID:             req.UID,
+		Kind:            req.Kind,
+		Resource:        req.Resource,
+		SubResource:     req.SubResource,
+		RequestKind:     req.RequestKind,
+		RequestResource: req.RequestResource,
+		Name:            req.Name,
+		Namespace:       req.Namespace,
+		Operation:       admissionv1.Operation(req.Operation),
+		UserInfo:        req.UserInfo,
+		Object:          req.Object,
+		OldObject:       req.OldObject,
+		DryRun:          req.DryRun,
+		Options:         req.Options,
+	}
+}
+
+// toV1beta1Response converts a v1 AdmissionResponse into its v1beta1
+// counterpart so it can be returned to webhook callers still speaking the
+// deprecated version.
+func toV1beta1Response(resp *admissionv1.AdmissionResponse) *v1beta1.AdmissionResponse {
+	if resp == nil {
+		return nil
+	}
+	out := &v1beta1.AdmissionResponse{
+		UID:              resp.UID,
+		Allowed:          resp.Allowed,
+		Result:           resp.Result,
+		Patch:            resp.Patch,
+		AuditAnnotations: resp.AuditAnnotations,
+	}
+	if resp.PatchType != nil {
+		pt := v1beta1.PatchType(*resp.PatchType)
+		out.PatchType = &pt
+	}
+	return out
+}
+
+// mutatePod is the AdmitFunc that evaluates the configured mutation rules
+// against the admitted pod and patches in whatever they match. Every
+// decision is counted by verdict and logged with the rules that fired, so
+// operators can audit exactly which pods were mutated and why.
+func mutatePod(req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	var pod corev1.Pod
+	var matchedRules []string
+
+	resp := func() *admissionv1.AdmissionResponse {
+		if err := json.Unmarshal(req.Object.Raw, &pod); err != nil {
+			decodeErrorsTotal.WithLabelValues("pod").Inc()
+			if failurePolicy == FailurePolicyIgnore {
+				return &admissionv1.AdmissionResponse{UID: req.UID, Allowed: true}
+			}
+			return &admissionv1.AdmissionResponse{
+				UID:     req.UID,
+				Allowed: false,
+				Result: &metav1.Status{
+					Message: fmt.Sprintf("could not unmarshal pod object: %v", err),
+					Reason:  metav1.StatusReasonInternalError,
+				},
+			}
+		}
+
+		if pod.Kind != "" && pod.Kind != "Pod" {
+			return &admissionv1.AdmissionResponse{
+				UID:     req.UID,
+				Allowed: false,
+				Result: &metav1.Status{
+					Message: "Invalid Kind for the request, only pods are supported for mutation",
+				},
+			}
+		}
+
+		mutated := pod.DeepCopy()
+		for _, rule := range rules {
+			matched, err := rule.Selector.matches(req.Namespace, mutated)
+			if err != nil {
+				if rule.effectiveFailurePolicy() == FailurePolicyIgnore {
+					log.Printf("rule %q: %v, skipping rule (failurePolicy=Ignore)", rule.Name, err)
+					continue
+				}
+				return &admissionv1.AdmissionResponse{
+					UID:     req.UID,
+					Allowed: false,
+					Result: &metav1.Status{
+						Message: fmt.Sprintf("rule %q: %v", rule.Name, err),
+						Reason:  metav1.StatusReasonInternalError,
+					},
+				}
+			}
+			if !matched {
+				continue
+			}
+			applyMutations(mutated, rule.Mutations)
+			matchedRules = append(matchedRules, rule.Name)
+			ruleMutationsTotal.WithLabelValues(rule.Name).Inc()
+		}
+
+		patch, err := constructPatch(&pod, mutated)
+		if err != nil {
+			if failurePolicy == FailurePolicyIgnore {
+				return &admissionv1.AdmissionResponse{UID: req.UID, Allowed: true}
+			}
+			return &admissionv1.AdmissionResponse{
+				UID:     req.UID,
+				Allowed: false,
+				Result: &metav1.Status{
+					Message: fmt.Sprintf("could not construct patch: %v", err),
+					Reason:  metav1.StatusReasonInternalError,
+				},
+			}
+		}
+
+		resp := &admissionv1.AdmissionResponse{
+			UID:     req.UID,
+			Allowed: true,
+		}
+		if len(patch) > 0 {
+			patchType := admissionv1.PatchTypeJSONPatch
+			resp.Patch = patch
+			resp.PatchType = &patchType
+		}
+		return resp
+	}()
+
+	admissionDecisionsTotal.WithLabelValues(verdict(resp)).Inc()
+	log.Printf("admission decision uid=%s namespace=%s pod=%s allowed=%t matchedRules=%v patch=%s",
+		req.UID, req.Namespace, pod.Name, resp.Allowed, matchedRules, resp.Patch)
+
+	return resp
+}
+
+// verdict classifies an AdmissionResponse for the admissionDecisionsTotal
+// metric: allowed, denied by policy, or errored while evaluating rules.
+func verdict(resp *admissionv1.AdmissionResponse) string {
+	if resp.Allowed {
+		return "allowed"
+	}
+	if resp.Result != nil && resp.Result.Reason == metav1.StatusReasonInternalError {
+		return "errored"
+	}
+	return "denied"
+}
+
+// applyMutations applies a single rule's Mutations to pod in place.
+func applyMutations(pod *corev1.Pod, m Mutations) {
+	if len(m.Tolerations) > 0 {
+		pod.Spec.Tolerations = mergeTolerations(pod.Spec.Tolerations, m.Tolerations)
+	}
+
+	if len(m.NodeSelector) > 0 {
+		if pod.Spec.NodeSelector == nil {
+			pod.Spec.NodeSelector = map[string]string{}
+		}
+		for k, v := range m.NodeSelector {
+			pod.Spec.NodeSelector[k] = v
+		}
+	}
+
+	if len(m.Labels) > 0 {
+		if pod.Labels == nil {
+			pod.Labels = map[string]string{}
+		}
+		for k, v := range m.Labels {
+			pod.Labels[k] = v
+		}
+	}
+
+	if len(m.Annotations) > 0 {
+		if pod.Annotations == nil {
+			pod.Annotations = map[string]string{}
+		}
+		for k, v := range m.Annotations {
+			pod.Annotations[k] = v
+		}
+	}
+
+	if len(m.Env) > 0 {
+		for i := range pod.Spec.Containers {
+			pod.Spec.Containers[i].Env = mergeEnv(pod.Spec.Containers[i].Env, m.Env)
+		}
+	}
+}
+
+// mergeTolerations appends additions to existing, skipping any addition that
+// the pod already declares.
+func mergeTolerations(existing, additions []corev1.Toleration) []corev1.Toleration {
+	merged := make([]corev1.Toleration, len(existing))
+	copy(merged, existing)
+
+	for _, a := range additions {
+		found := false
+		for _, e := range existing {
+			if reflect.DeepEqual(e, a) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, a)
+		}
+	}
+	return merged
+}
+
+// mergeEnv appends additions to existing, skipping any addition whose name
+// the container already defines.
+func mergeEnv(existing, additions []corev1.EnvVar) []corev1.EnvVar {
+	merged := make([]corev1.EnvVar, len(existing))
+	copy(merged, existing)
+
+	for _, a := range additions {
+		found := false
+		for _, e := range existing {
+			if e.Name == a.Name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, a)
+		}
+	}
+	return merged
+}
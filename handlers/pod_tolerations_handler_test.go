@@ -9,6 +9,7 @@ import (
 	"strings"
 	"testing"
 
+	admissionv1 "k8s.io/api/admission/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -17,7 +18,7 @@ import (
 	"k8s.io/api/admission/v1beta1"
 )
 
-const failed = "\u2717"
+const failed = "✗"
 
 // Init the route & tolerations for the unit tests.
 func init() {
@@ -25,17 +26,34 @@ func init() {
 	setDefaultTolerations()
 }
 
+// decodePatch unmarshals a JSON Patch into a version-agnostic slice of ops
+// so tests can assert on semantics rather than exact byte layout, which the
+// diff library is free to reorder.
+func decodePatch(t *testing.T, patch []byte) []map[string]interface{} {
+	t.Helper()
+	if len(patch) == 0 {
+		return nil
+	}
+	var ops []map[string]interface{}
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		t.Fatalf("\t%s\tcould not decode patch %s: %v", failed, patch, err)
+	}
+	return ops
+}
+
 func TestServe(t *testing.T) {
 	tests := []struct {
-		id          int
-		name        string
-		body        io.Reader
-		userAgent   string
-		method      string
-		contentType string
-		admit       AdmitFunc
-		wantStatus  int
-		wantResp    *v1beta1.AdmissionResponse
+		id           int
+		name         string
+		body         io.Reader
+		userAgent    string
+		method       string
+		contentType  string
+		admit        AdmitFunc
+		wantStatus   int
+		wantAllowed  bool
+		wantResponse bool
+		wantOps      []map[string]interface{}
 	}{
 		{
 			name: "Valid Request",
@@ -66,18 +84,24 @@ func TestServe(t *testing.T) {
 				return strings.NewReader(string(body))
 
 			}(),
-			method:      http.MethodPost,
-			contentType: "application/json",
-			admit:       mutatePod,
-			wantStatus:  http.StatusOK,
-			wantResp: &v1beta1.AdmissionResponse{
-				UID:     types.UID("70a7fc1a-a84b-4e9d-9e6e-500f45a4697b"),
-				Allowed: true,
-				Patch:   []byte(`[{"op":"replace","path":"/spec/tolerations","value":[{"key":"cloud.google.com/alloydb-host","operator":"Exists","effect":"NoSchedule"}]}]`),
-				PatchType: func() *v1beta1.PatchType {
-					pt := v1beta1.PatchTypeJSONPatch
-					return &pt
-				}(),
+			method:       http.MethodPost,
+			contentType:  "application/json",
+			admit:        mutatePod,
+			wantStatus:   http.StatusOK,
+			wantResponse: true,
+			wantAllowed:  true,
+			wantOps: []map[string]interface{}{
+				{
+					"op":   "add",
+					"path": "/spec/tolerations",
+					"value": []interface{}{
+						map[string]interface{}{
+							"key":      "cloud.google.com/alloydb-host",
+							"operator": "Exists",
+							"effect":   "NoSchedule",
+						},
+					},
+				},
 			},
 		},
 		{
@@ -113,7 +137,6 @@ func TestServe(t *testing.T) {
 			contentType: "text/plain",
 			admit:       mutatePod,
 			wantStatus:  http.StatusBadRequest,
-			wantResp:    nil,
 		},
 		{
 			name:        "Invalid JSON Request Body",
@@ -123,7 +146,6 @@ func TestServe(t *testing.T) {
 			method:      http.MethodPost,
 			admit:       mutatePod,
 			wantStatus:  http.StatusBadRequest,
-			wantResp:    nil,
 		},
 		{
 			name:        "Empty Request Body",
@@ -133,7 +155,6 @@ func TestServe(t *testing.T) {
 			method:      http.MethodPost,
 			admit:       mutatePod,
 			wantStatus:  http.StatusBadRequest,
-			wantResp:    nil,
 		},
 		{
 			name:        "Kubelet Probes",
@@ -144,7 +165,6 @@ func TestServe(t *testing.T) {
 			userAgent:   "Kubelet",
 			admit:       mutatePod,
 			wantStatus:  http.StatusOK,
-			wantResp:    nil,
 		},
 	}
 
@@ -162,91 +182,177 @@ func TestServe(t *testing.T) {
 				t.Errorf("\t%s\tTest ID=%d::Got status code %d, want %d", failed, tt.id, resp.StatusCode, tt.wantStatus)
 			}
 
-			if tt.wantResp != nil {
+			if tt.wantResponse {
 				gotResp := &v1beta1.AdmissionReview{}
 				if err := json.NewDecoder(resp.Body).Decode(gotResp); err != nil {
-					t.Errorf("\t%s\tTest ID=%d::Could not decode response: %v", failed, tt.id, err)
+					t.Fatalf("\t%s\tTest ID=%d::Could not decode response: %v", failed, tt.id, err)
+				}
+				if gotResp.Response.UID != types.UID("70a7fc1a-a84b-4e9d-9e6e-500f45a4697b") || gotResp.Response.Allowed != tt.wantAllowed {
+					t.Errorf("\t%s\tTest ID=%d::Got response %+v", failed, tt.id, gotResp.Response)
 				}
-				if !reflect.DeepEqual(gotResp.Response, tt.wantResp) {
-					t.Errorf("\t%s\tTest ID=%d::Got response %+v, want %+v", failed, tt.id, gotResp.Response, tt.wantResp)
+				if gotOps := decodePatch(t, gotResp.Response.Patch); !reflect.DeepEqual(gotOps, tt.wantOps) {
+					t.Errorf("\t%s\tTest ID=%d::Got patch ops %+v, want %+v", failed, tt.id, gotOps, tt.wantOps)
 				}
 			}
 		})
 	}
 }
 
+// TestServeAdmissionV1 exercises the same handler against the
+// admission.k8s.io/v1 wire format, which is all that clusters 1.22+ send.
+func TestServeAdmissionV1(t *testing.T) {
+	podBytes := []byte(`{"apiVersion": "v1", "kind": "Pod", "metadata": {"name": "fake-pod", "namespace": "fake-ns"}, "spec": {"containers": [{"name": "fake-container"}]}}`)
+	ar := &admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: admissionV1APIVersion,
+			Kind:       admissionReviewKind,
+		},
+		Request: &admissionv1.AdmissionRequest{
+			UID: types.UID("70a7fc1a-a84b-4e9d-9e6e-500f45a4697b"),
+			Kind: metav1.GroupVersionKind{
+				Group:   "",
+				Version: "v1",
+				Kind:    "Pod",
+			},
+			Resource: metav1.GroupVersionResource{
+				Group:    "",
+				Version:  "v1",
+				Resource: "pods",
+			},
+			Namespace: "fake-ns",
+			Operation: "CREATE",
+			Object: runtime.RawExtension{
+				Raw: podBytes,
+			},
+		},
+	}
+	body, err := json.Marshal(ar)
+	if err != nil {
+		t.Fatalf("could not marshal request: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/mutate", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	serve(rr, req, mutatePod)
+
+	resp := rr.Result()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("\t%s\tGot status code %d, want %d", failed, resp.StatusCode, http.StatusOK)
+	}
+
+	gotResp := &admissionv1.AdmissionReview{}
+	if err := json.NewDecoder(resp.Body).Decode(gotResp); err != nil {
+		t.Fatalf("\t%s\tCould not decode response: %v", failed, err)
+	}
+
+	if gotResp.APIVersion != admissionV1APIVersion || gotResp.Kind != admissionReviewKind {
+		t.Errorf("\t%s\tGot TypeMeta %+v, want apiVersion=%s kind=%s", failed, gotResp.TypeMeta, admissionV1APIVersion, admissionReviewKind)
+	}
+
+	if gotResp.Response.UID != types.UID("70a7fc1a-a84b-4e9d-9e6e-500f45a4697b") || !gotResp.Response.Allowed {
+		t.Errorf("\t%s\tGot response %+v", failed, gotResp.Response)
+	}
+
+	wantOps := []map[string]interface{}{
+		{
+			"op":   "add",
+			"path": "/spec/tolerations",
+			"value": []interface{}{
+				map[string]interface{}{
+					"key":      "cloud.google.com/alloydb-host",
+					"operator": "Exists",
+					"effect":   "NoSchedule",
+				},
+			},
+		},
+	}
+	if gotOps := decodePatch(t, gotResp.Response.Patch); !reflect.DeepEqual(gotOps, wantOps) {
+		t.Errorf("\t%s\tGot patch ops %+v, want %+v", failed, gotOps, wantOps)
+	}
+}
+
 func TestMutatePod(t *testing.T) {
 	tests := []struct {
-		name string
-		ar   *v1beta1.AdmissionReview
-		want *v1beta1.AdmissionResponse
+		name        string
+		req         *admissionv1.AdmissionRequest
+		wantAllowed bool
+		wantMessage string
+		wantOps     []map[string]interface{}
 	}{
 		{
 			name: "Valid Pod No Tolerations",
-			ar: &v1beta1.AdmissionReview{
-				Request: &v1beta1.AdmissionRequest{
-					UID: types.UID("70a7fc1a-a84b-4e9d-9e6e-500f45a4697b"),
-					Object: runtime.RawExtension{
-						Raw: []byte(`{"apiVersion": "v1", "kind": "Pod", "metadata": {"name": "fake-pod", "namespace": "fake-ns"}, "spec": {"containers": [{"name": "fake-container"}]}}`),
-					},
+			req: &admissionv1.AdmissionRequest{
+				UID: types.UID("70a7fc1a-a84b-4e9d-9e6e-500f45a4697b"),
+				Object: runtime.RawExtension{
+					Raw: []byte(`{"apiVersion": "v1", "kind": "Pod", "metadata": {"name": "fake-pod", "namespace": "fake-ns"}, "spec": {"containers": [{"name": "fake-container"}]}}`),
 				},
 			},
-			want: &v1beta1.AdmissionResponse{
-				UID:     types.UID("70a7fc1a-a84b-4e9d-9e6e-500f45a4697b"),
-				Allowed: true,
-				Patch:   []byte(`[{"op":"replace","path":"/spec/tolerations","value":[{"key":"cloud.google.com/alloydb-host","operator":"Exists","effect":"NoSchedule"}]}]`),
-				PatchType: func() *v1beta1.PatchType {
-					pt := v1beta1.PatchTypeJSONPatch
-					return &pt
-				}(),
+			wantAllowed: true,
+			wantOps: []map[string]interface{}{
+				{
+					"op":   "add",
+					"path": "/spec/tolerations",
+					"value": []interface{}{
+						map[string]interface{}{
+							"key":      "cloud.google.com/alloydb-host",
+							"operator": "Exists",
+							"effect":   "NoSchedule",
+						},
+					},
+				},
 			},
 		},
 		{
 			name: "Pod With Existing Tolerations",
-			ar: &v1beta1.AdmissionReview{
-				Request: &v1beta1.AdmissionRequest{
-					UID: types.UID("70a7fc1a-a84b-4e9d-9e6e-500f45a4697b"),
-					Object: runtime.RawExtension{
-						Raw: []byte(`{"apiVersion": "v1", "kind": "Pod", "metadata": {"name": "test-pod"}, "spec": {"tolerations": [{"key": "key1", "operator": "Equal", "value": "value1"}], "containers": [{"name": "test-container"}]}}`),
-					},
+			req: &admissionv1.AdmissionRequest{
+				UID: types.UID("70a7fc1a-a84b-4e9d-9e6e-500f45a4697b"),
+				Object: runtime.RawExtension{
+					Raw: []byte(`{"apiVersion": "v1", "kind": "Pod", "metadata": {"name": "test-pod"}, "spec": {"tolerations": [{"key": "key1", "operator": "Equal", "value": "value1"}], "containers": [{"name": "test-container"}]}}`),
 				},
 			},
-			want: &v1beta1.AdmissionResponse{
-				UID:     types.UID("70a7fc1a-a84b-4e9d-9e6e-500f45a4697b"),
-				Allowed: true,
-				Patch:   []byte(`[{"op":"replace","path":"/spec/tolerations","value":[{"key":"key1","operator":"Equal","value":"value1"},{"key":"cloud.google.com/alloydb-host","operator":"Exists","effect":"NoSchedule"}]}]`),
-				PatchType: func() *v1beta1.PatchType {
-					pt := v1beta1.PatchTypeJSONPatch
-					return &pt
-				}(),
+			wantAllowed: true,
+			wantOps: []map[string]interface{}{
+				{
+					"op":   "add",
+					"path": "/spec/tolerations/1",
+					"value": map[string]interface{}{
+						"key":      "cloud.google.com/alloydb-host",
+						"operator": "Exists",
+						"effect":   "NoSchedule",
+					},
+				},
 			},
 		},
 		{
 			name: "Invalid Kind",
-			ar: &v1beta1.AdmissionReview{
-				Request: &v1beta1.AdmissionRequest{
-					UID: types.UID("70a7fc1a-a84b-4e9d-9e6e-500f45a4697b"),
-					Object: runtime.RawExtension{
-						Raw: []byte(`{"apiVersion": "v1", "kind": "InvalidKind", "metadata": {"name": "test-pod"}, "spec": {"containers": [{"name": "test-container"}]}}`),
-					},
-				},
-			},
-			want: &v1beta1.AdmissionResponse{
-				UID:     types.UID("70a7fc1a-a84b-4e9d-9e6e-500f45a4697b"),
-				Allowed: false,
-				Result: &metav1.Status{
-					Message: "Invalid Kind for the request, only pods are supported for mutation",
+			req: &admissionv1.AdmissionRequest{
+				UID: types.UID("70a7fc1a-a84b-4e9d-9e6e-500f45a4697b"),
+				Object: runtime.RawExtension{
+					Raw: []byte(`{"apiVersion": "v1", "kind": "InvalidKind", "metadata": {"name": "test-pod"}, "spec": {"containers": [{"name": "test-container"}]}}`),
 				},
 			},
+			wantAllowed: false,
+			wantMessage: "Invalid Kind for the request, only pods are supported for mutation",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := mutatePod(tt.ar)
+			got := mutatePod(tt.req)
 
-			if !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("got response %+v, want %+v", got, tt.want)
+			if got.UID != tt.req.UID || got.Allowed != tt.wantAllowed {
+				t.Errorf("got response %+v", got)
+			}
+			if tt.wantMessage != "" {
+				if got.Result == nil || got.Result.Message != tt.wantMessage {
+					t.Errorf("got result %+v, want message %q", got.Result, tt.wantMessage)
+				}
+				return
+			}
+			if gotOps := decodePatch(t, got.Patch); !reflect.DeepEqual(gotOps, tt.wantOps) {
+				t.Errorf("got patch ops %+v, want %+v", gotOps, tt.wantOps)
 			}
 		})
 	}
@@ -254,42 +360,288 @@ func TestMutatePod(t *testing.T) {
 
 func TestConstructPatch(t *testing.T) {
 	tests := []struct {
-		name        string
-		tolerations []corev1.Toleration
-		want        []byte
+		name     string
+		original *corev1.Pod
+		mutated  *corev1.Pod
+		wantOps  []map[string]interface{}
 	}{
 		{
-			name: "Construct Patch",
-			tolerations: []corev1.Toleration{
+			name:     "Construct Patch",
+			original: &corev1.Pod{},
+			mutated: &corev1.Pod{
+				Spec: corev1.PodSpec{
+					Tolerations: []corev1.Toleration{
+						{
+							Key:      "key1",
+							Operator: corev1.TolerationOpEqual,
+							Value:    "value1",
+						},
+					},
+				},
+			},
+			wantOps: []map[string]interface{}{
 				{
-					Key:      "key1",
-					Operator: corev1.TolerationOpEqual,
-					Value:    "value1",
+					"op":   "add",
+					"path": "/spec/tolerations",
+					"value": []interface{}{
+						map[string]interface{}{
+							"key":      "key1",
+							"operator": "Equal",
+							"value":    "value1",
+						},
+					},
 				},
 			},
-			want: []byte(`[{"op":"replace","path":"/spec/tolerations","value":[{"key":"key1","operator":"Equal","value":"value1"}]}]`),
+		},
+		{
+			name:     "No Diff",
+			original: &corev1.Pod{},
+			mutated:  &corev1.Pod{},
+			wantOps:  nil,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := constructPatch(tt.tolerations)
+			got, err := constructPatch(tt.original, tt.mutated)
 			if err != nil {
 				t.Errorf("constructPatch() error = %v", err)
 			}
-			if string(got) != string(tt.want) {
-				t.Errorf("constructPatch() = %v, want %v", string(got), string(tt.want))
+			if gotOps := decodePatch(t, got); !reflect.DeepEqual(gotOps, tt.wantOps) {
+				t.Errorf("constructPatch() ops = %+v, want %+v", gotOps, tt.wantOps)
 			}
 		})
 	}
 }
 
+// TestRuleSelector verifies that a rule's namespace and label selectors are
+// honored, and that unmatched rules leave the pod untouched.
+func TestRuleSelector(t *testing.T) {
+	originalRules := rules
+	defer func() { rules = originalRules }()
+
+	rules = []Rule{
+		{
+			Name: "alloydb-nodes-in-prod",
+			Selector: Selector{
+				Namespaces: []string{"prod"},
+				LabelSelector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"alloydb": "true"},
+				},
+			},
+			Mutations: Mutations{
+				NodeSelector: map[string]string{"cloud.google.com/alloydb-host": "true"},
+			},
+		},
+	}
+
+	req := &admissionv1.AdmissionRequest{
+		UID:       types.UID("70a7fc1a-a84b-4e9d-9e6e-500f45a4697b"),
+		Namespace: "prod",
+		Object: runtime.RawExtension{
+			Raw: []byte(`{"apiVersion": "v1", "kind": "Pod", "metadata": {"name": "fake-pod", "labels": {"alloydb": "true"}}, "spec": {"containers": [{"name": "fake-container"}]}}`),
+		},
+	}
+
+	got := mutatePod(req)
+	if !got.Allowed {
+		t.Fatalf("\t%s\tgot Allowed=false, want true: %+v", failed, got.Result)
+	}
+	wantOps := []map[string]interface{}{
+		{
+			"op":   "add",
+			"path": "/spec/nodeSelector",
+			"value": map[string]interface{}{
+				"cloud.google.com/alloydb-host": "true",
+			},
+		},
+	}
+	if gotOps := decodePatch(t, got.Patch); !reflect.DeepEqual(gotOps, wantOps) {
+		t.Errorf("\t%s\tgot patch ops %+v, want %+v", failed, gotOps, wantOps)
+	}
+
+	req.Namespace = "staging"
+	got = mutatePod(req)
+	if got.Patch != nil {
+		t.Errorf("\t%s\tgot patch %s for unmatched namespace, want none", failed, got.Patch)
+	}
+}
+
+// TestFailurePolicy verifies that request-level decode failures are denied
+// with StatusBadRequest under the default "Fail" policy, and admitted
+// unmutated under "Ignore".
+func TestFailurePolicy(t *testing.T) {
+	originalPolicy := failurePolicy
+	defer func() { failurePolicy = originalPolicy }()
+
+	tests := []struct {
+		name        string
+		policy      string
+		wantStatus  int
+		wantAllowed bool
+	}{
+		{
+			name:       "Fail Closed",
+			policy:     FailurePolicyFail,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:        "Fail Open",
+			policy:      FailurePolicyIgnore,
+			wantStatus:  http.StatusOK,
+			wantAllowed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failurePolicy = tt.policy
+
+			req := httptest.NewRequest(http.MethodPost, "/mutate", strings.NewReader(`{"request":`))
+			req.Header.Set("Content-Type", "application/json")
+
+			rr := httptest.NewRecorder()
+			serve(rr, req, mutatePod)
+
+			resp := rr.Result()
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("\t%s\tGot status code %d, want %d", failed, resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			gotResp := &v1beta1.AdmissionReview{}
+			if err := json.NewDecoder(resp.Body).Decode(gotResp); err != nil {
+				t.Fatalf("\t%s\tCould not decode response: %v", failed, err)
+			}
+			if gotResp.Response.Allowed != tt.wantAllowed {
+				t.Errorf("\t%s\tGot response %+v, want Allowed=%t", failed, gotResp.Response, tt.wantAllowed)
+			}
+		})
+	}
+}
+
+// TestFailurePolicyAdmissionV1 verifies that a fail-open reply to a request
+// whose AdmissionReview decoded successfully as admission.k8s.io/v1, but
+// carries no Request, still comes back in that same apiVersion rather than
+// falling back to v1beta1.
+func TestFailurePolicyAdmissionV1(t *testing.T) {
+	originalPolicy := failurePolicy
+	defer func() { failurePolicy = originalPolicy }()
+	failurePolicy = FailurePolicyIgnore
+
+	ar := &admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: admissionV1APIVersion,
+			Kind:       admissionReviewKind,
+		},
+	}
+	body, err := json.Marshal(ar)
+	if err != nil {
+		t.Fatalf("could not marshal request: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/mutate", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	serve(rr, req, mutatePod)
+
+	resp := rr.Result()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("\t%s\tGot status code %d, want %d", failed, resp.StatusCode, http.StatusOK)
+	}
+
+	gotResp := &admissionv1.AdmissionReview{}
+	if err := json.NewDecoder(resp.Body).Decode(gotResp); err != nil {
+		t.Fatalf("\t%s\tCould not decode response: %v", failed, err)
+	}
+	if gotResp.APIVersion != admissionV1APIVersion || gotResp.Kind != admissionReviewKind {
+		t.Errorf("\t%s\tGot TypeMeta %+v, want apiVersion=%s kind=%s", failed, gotResp.TypeMeta, admissionV1APIVersion, admissionReviewKind)
+	}
+	if !gotResp.Response.Allowed {
+		t.Errorf("\t%s\tGot response %+v, want Allowed=true", failed, gotResp.Response)
+	}
+}
+
+// TestRuleFailurePolicy verifies that a rule whose Selector cannot be
+// evaluated is skipped, rather than denying the whole admission, when its
+// effective FailurePolicy is "Ignore".
+func TestRuleFailurePolicy(t *testing.T) {
+	originalRules, originalPolicy := rules, failurePolicy
+	defer func() { rules, failurePolicy = originalRules, originalPolicy }()
+	failurePolicy = FailurePolicyFail
+
+	badSelector := Selector{
+		LabelSelector: &metav1.LabelSelector{
+			MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: "alloydb", Operator: "NotAnOperator"},
+			},
+		},
+	}
+
+	req := &admissionv1.AdmissionRequest{
+		UID: types.UID("70a7fc1a-a84b-4e9d-9e6e-500f45a4697b"),
+		Object: runtime.RawExtension{
+			Raw: []byte(`{"apiVersion": "v1", "kind": "Pod", "metadata": {"name": "fake-pod"}, "spec": {"containers": [{"name": "fake-container"}]}}`),
+		},
+	}
+
+	rules = []Rule{{Name: "broken-selector", Selector: badSelector}}
+	got := mutatePod(req)
+	if got.Allowed {
+		t.Fatalf("\t%s\tgot Allowed=true for a broken rule under failurePolicy=Fail, want false", failed)
+	}
+
+	rules = []Rule{{Name: "broken-selector", Selector: badSelector, FailurePolicy: FailurePolicyIgnore}}
+	got = mutatePod(req)
+	if !got.Allowed {
+		t.Errorf("\t%s\tgot Allowed=false, want true: rule FailurePolicy=Ignore should skip the broken rule: %+v", failed, got.Result)
+	}
+}
+
+// TestDryRun exercises the same -test.pod entry point the CLI uses, so
+// mutation rules can be golden-file tested without standing up a server.
+func TestDryRun(t *testing.T) {
+	podJSON := []byte(`{"apiVersion": "v1", "kind": "Pod", "metadata": {"name": "fake-pod"}, "spec": {"containers": [{"name": "fake-container"}]}}`)
+
+	patch, err := DryRun(podJSON, "fake-ns")
+	if err != nil {
+		t.Fatalf("\t%s\tDryRun() error = %v", failed, err)
+	}
+
+	wantOps := []map[string]interface{}{
+		{
+			"op":   "add",
+			"path": "/spec/tolerations",
+			"value": []interface{}{
+				map[string]interface{}{
+					"key":      "cloud.google.com/alloydb-host",
+					"operator": "Exists",
+					"effect":   "NoSchedule",
+				},
+			},
+		},
+	}
+	if gotOps := decodePatch(t, patch); !reflect.DeepEqual(gotOps, wantOps) {
+		t.Errorf("\t%s\tDryRun() ops = %+v, want %+v", failed, gotOps, wantOps)
+	}
+}
+
 func setDefaultTolerations() {
-	tolerations = []corev1.Toleration{
+	rules = []Rule{
 		{
-			Key:      "cloud.google.com/alloydb-host",
-			Operator: corev1.TolerationOpExists,
-			Effect:   corev1.TaintEffectNoSchedule,
+			Name: "alloydb-tolerations",
+			Mutations: Mutations{
+				Tolerations: []corev1.Toleration{
+					{
+						Key:      "cloud.google.com/alloydb-host",
+						Operator: corev1.TolerationOpExists,
+						Effect:   corev1.TaintEffectNoSchedule,
+					},
+				},
+			},
 		},
 	}
 }
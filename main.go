@@ -0,0 +1,75 @@
+// Command alloydb-mutating-wh runs the AlloyDB pod mutating admission
+// webhook.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/rmishgoog/alloydb-mutating-wh/handlers"
+	"sigs.k8s.io/yaml"
+)
+
+func main() {
+	var (
+		port          = flag.String("port", "8443", "port the webhook HTTPS server listens on")
+		certFile      = flag.String("tlsCertFile", "/etc/webhook/certs/tls.crt", "path to the x509 certificate for HTTPS")
+		keyFile       = flag.String("tlsKeyFile", "/etc/webhook/certs/tls.key", "path to the x509 private key matching tlsCertFile")
+		configFile    = flag.String("config", "/etc/webhook/config/rules.yaml", "path to the mutation rule configuration file")
+		testPod       = flag.String("test.pod", "", "path to a pod manifest (YAML or JSON); if set, mutates it locally and exits instead of serving")
+		testNamespace = flag.String("test.namespace", "default", "namespace to pretend the pod in -test.pod is being admitted into")
+		testOut       = flag.String("test.out", "", "file to write the resulting JSON Patch to; defaults to stdout")
+	)
+	flag.Parse()
+
+	if err := handlers.LoadConfig(*configFile); err != nil {
+		log.Fatalf("could not load config: %v", err)
+	}
+
+	if *testPod != "" {
+		if err := dryRun(*testPod, *testNamespace, *testOut); err != nil {
+			log.Fatalf("dry run failed: %v", err)
+		}
+		return
+	}
+
+	handlers.Routes()
+
+	log.Printf("starting webhook server on :%s", *port)
+	if err := http.ListenAndServeTLS(":"+*port, *certFile, *keyFile, nil); err != nil {
+		log.Fatalf("could not start webhook server: %v", err)
+	}
+}
+
+// dryRun mutates the pod manifest at podPath, as if it were being admitted
+// into namespace, and writes the resulting JSON Patch to outPath (or
+// stdout, when outPath is empty). It never binds the HTTPS listener, so
+// mutation rules can be exercised and golden-file tested without a cluster.
+func dryRun(podPath, namespace, outPath string) error {
+	raw, err := os.ReadFile(podPath)
+	if err != nil {
+		return fmt.Errorf("could not read pod manifest %q: %w", podPath, err)
+	}
+
+	podJSON, err := yaml.YAMLToJSON(raw)
+	if err != nil {
+		return fmt.Errorf("could not parse pod manifest %q: %w", podPath, err)
+	}
+
+	patch, err := handlers.DryRun(podJSON, namespace)
+	if err != nil {
+		return fmt.Errorf("pod was not admitted: %w", err)
+	}
+
+	if outPath == "" {
+		fmt.Println(string(patch))
+		return nil
+	}
+	if err := os.WriteFile(outPath, patch, 0o644); err != nil {
+		return fmt.Errorf("could not write patch to %q: %w", outPath, err)
+	}
+	return nil
+}